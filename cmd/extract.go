@@ -0,0 +1,50 @@
+// Copyright © 2018 Vyacheslav Dutka
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/vsdutka/dnuz/pkg/dnuz"
+)
+
+// extractCmd operates on a local archive instead of downloading one first.
+var extractCmd = &cobra.Command{
+	Use:   "extract [path]",
+	Short: "Unzip&fix non utf8 paths/filenames of a local archive",
+	Long:  `Unzip&fix non utf8 paths/filenames of a local archive`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if len(args) != 1 {
+			return fmt.Errorf("requires exactly one archive path")
+		}
+		return nil
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		extractor := dnuz.NewExtractor(extractorOptions()...)
+		files, err := extractor.Extract(args[0], outPath)
+		if err != nil {
+			return err
+		}
+
+		printFiles(files)
+		return nil
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(extractCmd)
+}
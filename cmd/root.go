@@ -12,28 +12,24 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
+// Package cmd wires command-line flags to the dnuz library (pkg/dnuz).
+// It does no downloading or extracting itself.
 package cmd
 
 import (
-	"archive/zip"
-	"bytes"
 	"errors"
 	"fmt"
-	"io"
-	"io/ioutil"
 	"log"
 	"net/http"
 	"os"
-	"path/filepath"
 	"strings"
-
-	"golang.org/x/text/encoding"
-	"golang.org/x/text/encoding/charmap"
-	"golang.org/x/text/transform"
+	"time"
 
 	homedir "github.com/mitchellh/go-homedir"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
+
+	"github.com/vsdutka/dnuz/pkg/dnuz"
 )
 
 var (
@@ -42,8 +38,18 @@ var (
 	nonUtf8EncName string
 	outEncName     string
 	outPath        string
-	decoder        transform.Transformer
-	encoder        transform.Transformer
+	formatName     string
+	sha256Sum      string
+	md5Sum         string
+	retries        int
+	retryWait      time.Duration
+	httpTimeout    time.Duration
+	noProgress     bool
+	maxFiles       int
+	maxTotalSize   int64
+	maxFileSize    int64
+	jobs           int
+	manifestPath   string
 )
 
 // RootCmd represents the base command when called without any subcommands
@@ -55,106 +61,25 @@ var RootCmd = &cobra.Command{
 		if srcUrl == "" {
 			return errors.New("requires at least url")
 		}
-		var err error
-		decoder, err = getDecoder(nonUtf8EncName)
-		if err != nil {
-			return err
-		}
-		encoder, err = getEncoder(outEncName)
-		if err != nil {
-			return err
-		}
 		return nil
 	},
 	// Uncomment the following line if your bare application
 	// has an action associated with it:
 	Run: func(cmd *cobra.Command, args []string) {
-		res, err := http.Get(srcUrl)
+		downloader := dnuz.NewDownloader(downloaderOptions()...)
+		path, err := downloader.Download(srcUrl, dnuz.DestPath(srcUrl))
 		if err != nil {
 			log.Fatal(err)
 		}
-		defer res.Body.Close()
-		d, err := ioutil.ReadAll(res.Body)
-		if err != nil {
-			log.Fatal(err)
-		}
-		fmt.Printf("ReadFile: Size of download: %d\n", len(d))
-		files, err := func() ([]string, error) {
-			var filenames []string
-
-			r, err := zip.NewReader(bytes.NewReader(d), int64(len(d)))
-			//r, err := zip.OpenReader(src)
-			if err != nil {
-				return filenames, err
-			}
-
-			for _, f := range r.File {
-
-				rc, err := f.Open()
-				if err != nil {
-					return filenames, err
-				}
-				defer rc.Close()
-
-				// Store filename/path for returning and using later on
-				fname := f.Name
-				if f.NonUTF8 {
-					if decoder != nil {
-						// Разные кодировки = разные длины символов.
-						newFName := make([]byte, len(fname)*2)
-						n, _, err := decoder.Transform(newFName, []byte(fname), false)
-						if err != nil {
-							panic(err)
-						}
-						fname = string(newFName[:n])
-					}
-				}
-				fpath := strings.ToLower(filepath.Join(outPath, fname))
-				if encoder != nil {
-					newFPath := make([]byte, len(fpath)*2)
-					n, _, err := encoder.Transform(newFPath, []byte(fpath), false)
-					if err != nil {
-						panic(err)
-					}
-					fpath = string(newFPath[:n])
-				}
-				filenames = append(filenames, fpath)
-
-				if f.FileInfo().IsDir() {
-
-					// Make Folder
-					os.MkdirAll(fpath, os.ModePerm)
-
-				} else {
-
-					// Make File
-					if err = os.MkdirAll(filepath.Dir(fpath), os.ModePerm); err != nil {
-						return filenames, err
-					}
-
-					outFile, err := os.OpenFile(fpath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, f.Mode())
-					if err != nil {
-						return filenames, err
-					}
-
-					_, err = io.Copy(outFile, rc)
-
-					// Close the file without defer to close before next iteration of loop
-					outFile.Close()
-
-					if err != nil {
-						return filenames, err
-					}
-
-				}
-			}
-			return filenames, nil
-		}()
+		defer os.Remove(path)
+
+		extractor := dnuz.NewExtractor(extractorOptions()...)
+		files, err := extractor.Extract(path, outPath)
 		if err != nil {
 			log.Fatal(err)
 		}
 
-		fmt.Println("Unzipped:\n" + strings.Join(files, "\n"))
+		printFiles(files)
 	},
 }
 
@@ -180,8 +105,20 @@ func init() {
 	RootCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
 	RootCmd.PersistentFlags().StringVar(&srcUrl, "src-url", "", "Source file url")
 	RootCmd.PersistentFlags().StringVar(&outPath, "out-path", "", "Output path")
-	RootCmd.PersistentFlags().StringVar(&nonUtf8EncName, "nonUtf8-enc", "", "Encoding name for nonUTF8 filenames")
+	RootCmd.PersistentFlags().StringVar(&nonUtf8EncName, "nonUtf8-enc", "", "Encoding name for nonUTF8 filenames (IANA/MIME name, e.g. gbk, shift_jis, euc-kr, utf-16le), or \"auto\" to detect it")
 	RootCmd.PersistentFlags().StringVar(&outEncName, "out-enc", "", "Encoding name for filenames")
+	RootCmd.PersistentFlags().StringVar(&formatName, "format", "", "Archive format (zip, tar, tar.gz, tar.bz2, tar.xz, 7z, rar); auto-detected from magic bytes when empty")
+	RootCmd.PersistentFlags().StringVar(&sha256Sum, "sha256", "", "Expected SHA-256 digest of the downloaded file; verified before extraction")
+	RootCmd.PersistentFlags().StringVar(&md5Sum, "md5", "", "Expected MD5 digest of the downloaded file; verified before extraction")
+	RootCmd.PersistentFlags().IntVar(&retries, "retries", 3, "Number of times to retry a failed download")
+	RootCmd.PersistentFlags().DurationVar(&retryWait, "retry-wait", 2*time.Second, "Wait time between download retries")
+	RootCmd.PersistentFlags().DurationVar(&httpTimeout, "timeout", 0, "HTTP client timeout for downloads (0 = no timeout)")
+	RootCmd.PersistentFlags().BoolVar(&noProgress, "no-progress", false, "Disable the download progress bar")
+	RootCmd.PersistentFlags().IntVar(&maxFiles, "max-files", 0, "Maximum number of files to extract (0 = unlimited); guards against zip bombs")
+	RootCmd.PersistentFlags().Int64Var(&maxTotalSize, "max-total-size", 0, "Maximum total uncompressed bytes to extract (0 = unlimited)")
+	RootCmd.PersistentFlags().Int64Var(&maxFileSize, "max-file-size", 0, "Maximum size in bytes of any single extracted file (0 = unlimited)")
+	RootCmd.PersistentFlags().IntVar(&jobs, "jobs", 0, "Number of entries to extract concurrently (0 = runtime.NumCPU())")
+	RootCmd.PersistentFlags().StringVar(&manifestPath, "manifest", "", "Write a manifest of extracted files (name, path, size, mode, sha256) to this path; \".json\" gets a JSON array, anything else gets sha256sum-style lines")
 }
 
 // initConfig reads in config file and ENV variables if set.
@@ -210,41 +147,42 @@ func initConfig() {
 	}
 }
 
-func getEncoding(encName string) (encoding.Encoding, error) {
-	if encName != "" {
-		switch strings.ToLower(encName) {
-		case "866":
-			return charmap.CodePage866, nil
-		case "cp866":
-			return charmap.CodePage866, nil
-		case "1251":
-			return charmap.Windows1251, nil
-		case "windows-1251":
-			return charmap.Windows1251, nil
-		default:
-			return nil, errors.New("Unsupported encoding name\"" + encName + "\"")
-		}
+// downloaderOptions builds dnuz.Option values from the download-related
+// flags, shared by RootCmd and any other command that downloads.
+func downloaderOptions() []dnuz.Option {
+	return []dnuz.Option{
+		dnuz.WithHTTPClient(&http.Client{Timeout: httpTimeout}),
+		dnuz.WithRetries(retries, retryWait),
+		dnuz.WithProgress(!noProgress),
+		dnuz.WithChecksum(sha256Sum, md5Sum),
 	}
-	return encoding.Nop, nil
 }
 
-func getDecoder(encName string) (transform.Transformer, error) {
-	e, err := getEncoding(encName)
-	if err != nil {
-		return nil, err
+// extractorOptions builds dnuz.Option values from the extraction-related
+// flags, shared by RootCmd and extractCmd.
+func extractorOptions() []dnuz.Option {
+	opts := []dnuz.Option{
+		dnuz.WithEncoding(nonUtf8EncName),
+		dnuz.WithOutEncoding(outEncName),
+		dnuz.WithLimits(maxFiles, maxTotalSize, maxFileSize),
 	}
-	if e == nil {
-		return nil, nil
+	if formatName != "" {
+		opts = append(opts, dnuz.WithFormatName(formatName))
 	}
-	return e.NewDecoder(), nil
-}
-func getEncoder(encName string) (transform.Transformer, error) {
-	e, err := getEncoding(encName)
-	if err != nil {
-		return nil, err
+	if jobs > 0 {
+		opts = append(opts, dnuz.WithJobs(jobs))
 	}
-	if e == nil {
-		return nil, nil
+	if manifestPath != "" {
+		opts = append(opts, dnuz.WithManifest(manifestPath))
+	}
+	return opts
+}
+
+// printFiles reports what was extracted, in the CLI's traditional format.
+func printFiles(files []dnuz.ExtractedFile) {
+	names := make([]string, len(files))
+	for i, f := range files {
+		names[i] = f.Path
 	}
-	return e.NewEncoder(), nil
+	fmt.Println("Unzipped:\n" + strings.Join(names, "\n"))
 }
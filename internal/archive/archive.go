@@ -0,0 +1,117 @@
+// Copyright © 2018 Vyacheslav Dutka
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package archive provides a small set of pluggable Extractor implementations
+// (zip, tar and its compressed variants, 7z and rar) behind one interface, so
+// the rest of dnuz can treat every archive format the same way.
+package archive
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Format identifies an archive container recognised by this package.
+type Format string
+
+// Supported formats. Values are also accepted as the --format flag.
+const (
+	FormatZip    Format = "zip"
+	FormatTar    Format = "tar"
+	FormatTarGz  Format = "tar.gz"
+	FormatTarBz2 Format = "tar.bz2"
+	FormatTarXz  Format = "tar.xz"
+	Format7z     Format = "7z"
+	FormatRar    Format = "rar"
+)
+
+// Entry is one file or directory inside an archive, normalised across
+// formats so the existing filename decoder/encoder pipeline can be applied
+// uniformly regardless of which Extractor produced it.
+type Entry struct {
+	Name      string
+	Size      int64
+	Mode      os.FileMode
+	IsDir     bool
+	IsSymlink bool
+	NonUTF8   bool
+	// Open returns the entry's content. For a symlink entry this is its
+	// link target, not file data, matching how zip itself stores symlinks.
+	Open func() (io.ReadCloser, error)
+}
+
+// Extractor lists and opens the entries of a single archive.
+type Extractor interface {
+	// Entries returns the archive's contents. Implementations read the
+	// archive's directory/header information eagerly. The zip and 7z
+	// extractors only read entry bodies when Open is called; the tar and
+	// rar extractors have already spooled every body to a temp directory
+	// when they are created.
+	Entries() ([]*Entry, error)
+	// Close releases any resources (open file handles, spool directories)
+	// held by the extractor.
+	Close() error
+}
+
+var magicNumbers = []struct {
+	format Format
+	magic  []byte
+}{
+	{FormatZip, []byte("PK\x03\x04")},
+	{FormatTarGz, []byte{0x1f, 0x8b}},
+	{FormatTarBz2, []byte("BZh")},
+	{FormatTarXz, []byte{0xfd, '7', 'z', 'X', 'Z', 0x00}},
+	{Format7z, []byte{'7', 'z', 0xbc, 0xaf, 0x27, 0x1c}},
+	{FormatRar, []byte("Rar!\x1a\x07")},
+}
+
+// Sniff inspects the magic bytes at the start of buf and returns the
+// archive format they identify. Plain tar has no magic number of its own,
+// so Sniff falls back to FormatTar when nothing else matches; callers that
+// want a hard failure on unrecognised input should validate the result
+// against the actual content (e.g. by attempting to open it).
+func Sniff(buf []byte) Format {
+	for _, m := range magicNumbers {
+		if bytes.HasPrefix(buf, m.magic) {
+			return m.format
+		}
+	}
+	return FormatTar
+}
+
+// New opens the archive at path, assuming it is encoded in the given
+// format, and returns an Extractor for it. Callers must call Close when
+// done.
+func New(format Format, path string) (Extractor, error) {
+	switch format {
+	case FormatZip:
+		return newZipExtractor(path)
+	case FormatTar:
+		return newTarExtractor(path, nil)
+	case FormatTarGz:
+		return newTarExtractor(path, gzipReader)
+	case FormatTarBz2:
+		return newTarExtractor(path, bzip2Reader)
+	case FormatTarXz:
+		return newTarExtractor(path, xzReader)
+	case Format7z:
+		return newSevenZipExtractor(path)
+	case FormatRar:
+		return newRarExtractor(path)
+	default:
+		return nil, fmt.Errorf("archive: unsupported format %q", format)
+	}
+}
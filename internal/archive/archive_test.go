@@ -0,0 +1,155 @@
+// Copyright © 2018 Vyacheslav Dutka
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package archive
+
+import (
+	"archive/tar"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSniff(t *testing.T) {
+	tests := []struct {
+		name string
+		buf  []byte
+		want Format
+	}{
+		{"zip", []byte("PK\x03\x04rest"), FormatZip},
+		{"gzip", []byte{0x1f, 0x8b, 0x08}, FormatTarGz},
+		{"bzip2", []byte("BZhrest"), FormatTarBz2},
+		{"xz", []byte{0xfd, '7', 'z', 'X', 'Z', 0x00, 0x00}, FormatTarXz},
+		{"7z", []byte{'7', 'z', 0xbc, 0xaf, 0x27, 0x1c, 0x00}, Format7z},
+		{"rar", []byte("Rar!\x1a\x07\x00"), FormatRar},
+		{"plain tar / unrecognised", []byte("not a known magic"), FormatTar},
+		{"empty", nil, FormatTar},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Sniff(tt.buf); got != tt.want {
+				t.Errorf("Sniff(%q) = %q, want %q", tt.buf, got, tt.want)
+			}
+		})
+	}
+}
+
+// writeTestTar builds a plain (uncompressed) tar archive at dir/name
+// containing a regular file, a directory and a symlink entry.
+func writeTestTar(t *testing.T, dir, name string) string {
+	t.Helper()
+
+	path := filepath.Join(dir, name)
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("create tar: %v", err)
+	}
+	defer f.Close()
+
+	tw := tar.NewWriter(f)
+	dirHdr := &tar.Header{Name: "sub/", Typeflag: tar.TypeDir, Mode: 0755}
+	if err := tw.WriteHeader(dirHdr); err != nil {
+		t.Fatalf("write dir header: %v", err)
+	}
+
+	content := []byte("hello from tar")
+	fileHdr := &tar.Header{Name: "sub/file.txt", Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len(content))}
+	if err := tw.WriteHeader(fileHdr); err != nil {
+		t.Fatalf("write file header: %v", err)
+	}
+	if _, err := tw.Write(content); err != nil {
+		t.Fatalf("write file content: %v", err)
+	}
+
+	linkHdr := &tar.Header{Name: "sub/link", Typeflag: tar.TypeSymlink, Linkname: "../outside", Mode: 0777}
+	if err := tw.WriteHeader(linkHdr); err != nil {
+		t.Fatalf("write symlink header: %v", err)
+	}
+
+	if err := tw.Close(); err != nil {
+		t.Fatalf("close tar: %v", err)
+	}
+	return path
+}
+
+func TestTarExtractorEntries(t *testing.T) {
+	dir := t.TempDir()
+	path := writeTestTar(t, dir, "test.tar")
+
+	ex, err := New(FormatTar, path)
+	if err != nil {
+		t.Fatalf("New(FormatTar): %v", err)
+	}
+	defer ex.Close()
+
+	entries, err := ex.Entries()
+	if err != nil {
+		t.Fatalf("Entries: %v", err)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("got %d entries, want 3", len(entries))
+	}
+
+	byName := make(map[string]*Entry, len(entries))
+	for _, e := range entries {
+		byName[e.Name] = e
+	}
+
+	dirEntry, ok := byName["sub/"]
+	if !ok || !dirEntry.IsDir {
+		t.Fatalf("expected a directory entry for %q, got %+v", "sub/", dirEntry)
+	}
+
+	fileEntry, ok := byName["sub/file.txt"]
+	if !ok {
+		t.Fatal("missing file entry")
+	}
+	rc, err := fileEntry.Open()
+	if err != nil {
+		t.Fatalf("Open file entry: %v", err)
+	}
+	got, err := ioutil.ReadAll(rc)
+	rc.Close()
+	if err != nil {
+		t.Fatalf("read file entry: %v", err)
+	}
+	if string(got) != "hello from tar" {
+		t.Fatalf("file content = %q, want %q", got, "hello from tar")
+	}
+
+	linkEntry, ok := byName["sub/link"]
+	if !ok || !linkEntry.IsSymlink {
+		t.Fatalf("expected a symlink entry for %q, got %+v", "sub/link", linkEntry)
+	}
+	rc, err = linkEntry.Open()
+	if err != nil {
+		t.Fatalf("Open symlink entry: %v", err)
+	}
+	target, err := ioutil.ReadAll(rc)
+	rc.Close()
+	if err != nil {
+		t.Fatalf("read symlink entry: %v", err)
+	}
+	if string(target) != "../outside" {
+		t.Fatalf("symlink target = %q, want %q", target, "../outside")
+	}
+}
+
+func TestNewUnsupportedFormat(t *testing.T) {
+	if _, err := New(Format("exe"), "whatever"); err == nil {
+		t.Fatal("New with an unsupported format: want error, got nil")
+	}
+}
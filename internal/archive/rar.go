@@ -0,0 +1,96 @@
+package archive
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"unicode/utf8"
+
+	"github.com/nwaples/rardecode"
+)
+
+// rar, like tar, only supports sequential reads, so entries are spooled to
+// a temp directory up front; see tar.go for why that keeps Open symmetrical
+// with the random-access formats.
+type rarExtractor struct {
+	tmpDir  string
+	entries []*Entry
+}
+
+func newRarExtractor(path string) (Extractor, error) {
+	r, err := rardecode.OpenReader(path, "")
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	tmpDir, err := ioutil.TempDir("", "dnuz-rar-")
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := spoolRar(r, tmpDir)
+	if err != nil {
+		os.RemoveAll(tmpDir)
+		return nil, err
+	}
+	return &rarExtractor{tmpDir: tmpDir, entries: entries}, nil
+}
+
+func spoolRar(r *rardecode.ReadCloser, tmpDir string) ([]*Entry, error) {
+	var entries []*Entry
+	i := 0
+	for {
+		hdr, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if hdr.IsDir {
+			entries = append(entries, &Entry{
+				Name:    hdr.Name,
+				Mode:    hdr.Mode(),
+				IsDir:   true,
+				NonUTF8: !utf8.ValidString(hdr.Name),
+				Open:    func() (io.ReadCloser, error) { return ioutil.NopCloser(bytes.NewReader(nil)), nil },
+			})
+			continue
+		}
+
+		spoolPath := filepath.Join(tmpDir, strconv.Itoa(i))
+		i++
+		sf, err := os.Create(spoolPath)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := io.Copy(sf, r); err != nil {
+			sf.Close()
+			return nil, err
+		}
+		sf.Close()
+
+		entries = append(entries, &Entry{
+			Name:      hdr.Name,
+			Size:      hdr.UnPackedSize,
+			Mode:      hdr.Mode(),
+			IsSymlink: hdr.Mode()&os.ModeSymlink != 0,
+			NonUTF8:   !utf8.ValidString(hdr.Name),
+			Open:      func() (io.ReadCloser, error) { return os.Open(spoolPath) },
+		})
+	}
+	return entries, nil
+}
+
+func (e *rarExtractor) Entries() ([]*Entry, error) {
+	return e.entries, nil
+}
+
+func (e *rarExtractor) Close() error {
+	return os.RemoveAll(e.tmpDir)
+}
@@ -0,0 +1,42 @@
+package archive
+
+import (
+	"io"
+	"os"
+	"unicode/utf8"
+
+	"github.com/bodgit/sevenzip"
+)
+
+type sevenZipExtractor struct {
+	rc *sevenzip.ReadCloser
+}
+
+func newSevenZipExtractor(path string) (Extractor, error) {
+	rc, err := sevenzip.OpenReader(path)
+	if err != nil {
+		return nil, err
+	}
+	return &sevenZipExtractor{rc: rc}, nil
+}
+
+func (e *sevenZipExtractor) Entries() ([]*Entry, error) {
+	entries := make([]*Entry, 0, len(e.rc.File))
+	for _, f := range e.rc.File {
+		f := f
+		entries = append(entries, &Entry{
+			Name:      f.Name,
+			Size:      int64(f.UncompressedSize),
+			Mode:      f.Mode(),
+			IsDir:     f.FileInfo().IsDir(),
+			IsSymlink: f.Mode()&os.ModeSymlink != 0,
+			NonUTF8:   !utf8.ValidString(f.Name),
+			Open:      func() (io.ReadCloser, error) { return f.Open() },
+		})
+	}
+	return entries, nil
+}
+
+func (e *sevenZipExtractor) Close() error {
+	return e.rc.Close()
+}
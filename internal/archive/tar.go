@@ -0,0 +1,125 @@
+package archive
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"unicode/utf8"
+
+	"github.com/ulikunitz/xz"
+)
+
+// tar is read sequentially and has no central directory, so entry bodies
+// are spooled to a temp directory up front; Open then just re-opens the
+// spooled file. This keeps Entries()/Open() symmetrical with the
+// random-access formats (zip, 7z) without buffering the whole archive in
+// memory.
+type tarExtractor struct {
+	tmpDir  string
+	entries []*Entry
+}
+
+func gzipReader(r io.Reader) (io.Reader, error)  { return gzip.NewReader(r) }
+func bzip2Reader(r io.Reader) (io.Reader, error) { return bzip2.NewReader(r), nil }
+func xzReader(r io.Reader) (io.Reader, error)    { return xz.NewReader(r) }
+
+func newTarExtractor(path string, decomp func(io.Reader) (io.Reader, error)) (Extractor, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if decomp != nil {
+		dr, err := decomp(f)
+		if err != nil {
+			return nil, err
+		}
+		r = dr
+	}
+
+	tmpDir, err := ioutil.TempDir("", "dnuz-tar-")
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := spoolTar(tar.NewReader(r), tmpDir)
+	if err != nil {
+		os.RemoveAll(tmpDir)
+		return nil, err
+	}
+	return &tarExtractor{tmpDir: tmpDir, entries: entries}, nil
+}
+
+func spoolTar(tr *tar.Reader, tmpDir string) ([]*Entry, error) {
+	var entries []*Entry
+	i := 0
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if hdr.Typeflag == tar.TypeDir {
+			entries = append(entries, &Entry{
+				Name:    hdr.Name,
+				Mode:    hdr.FileInfo().Mode(),
+				IsDir:   true,
+				NonUTF8: !utf8.ValidString(hdr.Name),
+				Open:    func() (io.ReadCloser, error) { return ioutil.NopCloser(bytes.NewReader(nil)), nil },
+			})
+			continue
+		}
+
+		if hdr.Typeflag == tar.TypeSymlink {
+			target := hdr.Linkname
+			entries = append(entries, &Entry{
+				Name:      hdr.Name,
+				Mode:      hdr.FileInfo().Mode(),
+				IsSymlink: true,
+				NonUTF8:   !utf8.ValidString(hdr.Name),
+				Open:      func() (io.ReadCloser, error) { return ioutil.NopCloser(bytes.NewReader([]byte(target))), nil },
+			})
+			continue
+		}
+
+		spoolPath := filepath.Join(tmpDir, strconv.Itoa(i))
+		i++
+		sf, err := os.Create(spoolPath)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := io.Copy(sf, tr); err != nil {
+			sf.Close()
+			return nil, err
+		}
+		sf.Close()
+
+		entries = append(entries, &Entry{
+			Name:    hdr.Name,
+			Size:    hdr.Size,
+			Mode:    hdr.FileInfo().Mode(),
+			NonUTF8: !utf8.ValidString(hdr.Name),
+			Open:    func() (io.ReadCloser, error) { return os.Open(spoolPath) },
+		})
+	}
+	return entries, nil
+}
+
+func (e *tarExtractor) Entries() ([]*Entry, error) {
+	return e.entries, nil
+}
+
+func (e *tarExtractor) Close() error {
+	return os.RemoveAll(e.tmpDir)
+}
@@ -0,0 +1,40 @@
+package archive
+
+import (
+	"archive/zip"
+	"io"
+	"os"
+)
+
+type zipExtractor struct {
+	rc *zip.ReadCloser
+}
+
+func newZipExtractor(path string) (Extractor, error) {
+	rc, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, err
+	}
+	return &zipExtractor{rc: rc}, nil
+}
+
+func (e *zipExtractor) Entries() ([]*Entry, error) {
+	entries := make([]*Entry, 0, len(e.rc.File))
+	for _, f := range e.rc.File {
+		f := f
+		entries = append(entries, &Entry{
+			Name:      f.Name,
+			Size:      int64(f.UncompressedSize64),
+			Mode:      f.Mode(),
+			IsDir:     f.FileInfo().IsDir(),
+			IsSymlink: f.Mode()&os.ModeSymlink != 0,
+			NonUTF8:   f.NonUTF8,
+			Open:      func() (io.ReadCloser, error) { return f.Open() },
+		})
+	}
+	return entries, nil
+}
+
+func (e *zipExtractor) Close() error {
+	return e.rc.Close()
+}
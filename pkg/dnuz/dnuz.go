@@ -0,0 +1,59 @@
+// Copyright © 2018 Vyacheslav Dutka
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package dnuz is the library behind the dnuz CLI: downloading an archive
+// (or reading one already on disk) and extracting it while fixing up
+// non-UTF8 filenames. The cmd package is a thin flag-parsing wrapper
+// around Downloader and Extractor.
+package dnuz
+
+import (
+	"log/slog"
+	"os"
+)
+
+// Logger is the subset of *slog.Logger that dnuz calls into, so callers
+// can plug in their own structured logger instead.
+type Logger interface {
+	Debug(msg string, args ...any)
+	Info(msg string, args ...any)
+	Warn(msg string, args ...any)
+	Error(msg string, args ...any)
+}
+
+var _ Logger = (*slog.Logger)(nil)
+
+func defaultLogger() Logger {
+	return slog.New(slog.NewTextHandler(os.Stderr, nil))
+}
+
+// ExtractedFile describes one entry written out by Extractor.Extract.
+type ExtractedFile struct {
+	// Name is the entry's original name inside the archive.
+	Name string
+	// DecodedName is Name after non-UTF8 decoding (WithEncoding), before
+	// sanitizing against outPath or re-encoding (WithOutEncoding). Equal
+	// to Name when NonUTF8 is false.
+	DecodedName string
+	// Path is where it was written, after decoding/encoding and
+	// sanitizing against outPath.
+	Path    string
+	Size    int64
+	Mode    os.FileMode
+	IsDir   bool
+	NonUTF8 bool
+	// SHA256 is the hex digest of the written file's content, computed
+	// while it was copied out. Empty for directories and symlinks.
+	SHA256 string
+}
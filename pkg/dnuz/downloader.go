@@ -0,0 +1,242 @@
+// Copyright © 2018 Vyacheslav Dutka
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dnuz
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	progressbar "github.com/schollz/progressbar/v3"
+)
+
+// Downloader streams an archive to disk, resuming a previous partial
+// download when possible. It always writes to a real OS path: zip/7z/rar
+// readers need random access to a real file, so, unlike Extractor, a
+// Downloader ignores WithFS.
+type Downloader struct {
+	opts *options
+}
+
+// NewDownloader builds a Downloader. See WithHTTPClient, WithRetries,
+// WithProgress and WithChecksum for the options it understands.
+func NewDownloader(opts ...Option) *Downloader {
+	o := defaultOptions()
+	for _, opt := range opts {
+		opt(o)
+	}
+	return &Downloader{opts: o}
+}
+
+// downloadMeta is the sidecar persisted next to a .part file so an
+// interrupted download can be resumed later, even across separate runs,
+// as long as the server still recognises the same ETag/Last-Modified.
+type downloadMeta struct {
+	URL           string `json:"url"`
+	ETag          string `json:"etag,omitempty"`
+	LastModified  string `json:"last_modified,omitempty"`
+	BytesReceived int64  `json:"bytes_received"`
+}
+
+// DestPath returns a stable path to download url into, so a later call
+// against the same url can find and resume the .part file this one left
+// behind.
+func DestPath(url string) string {
+	h := sha1.Sum([]byte(url))
+	return filepath.Join(os.TempDir(), fmt.Sprintf("dnuz-%x.download", h))
+}
+
+// Download streams url to destPath, resuming a previous partial download
+// when possible, retrying transient failures, and verifying the result
+// against WithChecksum if given. It returns destPath on success.
+func (d *Downloader) Download(url, destPath string) (string, error) {
+	partPath := destPath + ".part"
+	metaPath := destPath + ".meta"
+
+	meta := downloadMeta{URL: url}
+	if existing, err := loadMeta(metaPath); err == nil && existing.URL == url {
+		meta = *existing
+	}
+
+	var lastErr error
+	for attempt := 0; attempt <= d.opts.retries; attempt++ {
+		if attempt > 0 {
+			d.opts.logger.Info("retrying download", "url", url, "attempt", attempt, "of", d.opts.retries, "error", lastErr)
+			time.Sleep(d.opts.retryWait)
+		}
+		if lastErr = d.attempt(&meta, partPath, metaPath); lastErr == nil {
+			break
+		}
+	}
+	if lastErr != nil {
+		return "", fmt.Errorf("download %s: %w", url, lastErr)
+	}
+
+	if err := verifyChecksum(partPath, d.opts.sha256, d.opts.md5); err != nil {
+		// The part file on disk doesn't match what was requested, so it
+		// must not be resumed from later: drop it and its sidecar so the
+		// next Download call starts clean instead of sending a Range
+		// request for bytes that are already wrong.
+		os.Remove(partPath)
+		os.Remove(metaPath)
+		return "", err
+	}
+
+	if err := os.Rename(partPath, destPath); err != nil {
+		return "", err
+	}
+	os.Remove(metaPath)
+	return destPath, nil
+}
+
+// attempt performs a single GET, resuming from meta.BytesReceived via a
+// Range request when the part file on disk still matches it.
+func (d *Downloader) attempt(meta *downloadMeta, partPath, metaPath string) error {
+	offset := int64(0)
+	if fi, err := os.Stat(partPath); err == nil && fi.Size() == meta.BytesReceived {
+		offset = meta.BytesReceived
+	} else {
+		meta.BytesReceived = 0
+	}
+
+	req, err := http.NewRequest("GET", meta.URL, nil)
+	if err != nil {
+		return err
+	}
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+		if meta.ETag != "" {
+			req.Header.Set("If-Range", meta.ETag)
+		} else if meta.LastModified != "" {
+			req.Header.Set("If-Range", meta.LastModified)
+		}
+	}
+
+	res, err := d.opts.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	flags := os.O_WRONLY | os.O_CREATE
+	switch res.StatusCode {
+	case http.StatusPartialContent:
+		flags |= os.O_APPEND
+	case http.StatusOK:
+		// Server ignored the Range request (or there was nothing to
+		// resume); start the part file over.
+		flags |= os.O_TRUNC
+		offset = 0
+		meta.BytesReceived = 0
+	default:
+		return fmt.Errorf("unexpected status %s", res.Status)
+	}
+	meta.ETag = res.Header.Get("ETag")
+	meta.LastModified = res.Header.Get("Last-Modified")
+
+	out, err := os.OpenFile(partPath, flags, 0644)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	var w io.Writer = out
+	var bar *progressbar.ProgressBar
+	if d.opts.progress {
+		total := int64(-1)
+		if res.ContentLength >= 0 {
+			total = offset + res.ContentLength
+		}
+		bar = progressbar.DefaultBytes(total, "downloading")
+		bar.Add64(offset)
+		w = io.MultiWriter(out, bar)
+	}
+
+	n, copyErr := io.Copy(w, res.Body)
+	meta.BytesReceived = offset + n
+	if err := saveMeta(metaPath, meta); err != nil {
+		return err
+	}
+	return copyErr
+}
+
+func loadMeta(path string) (*downloadMeta, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var m downloadMeta
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+func saveMeta(path string, meta *downloadMeta) error {
+	b, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, b, 0644)
+}
+
+// verifyChecksum checks path against sha256Sum/md5Sum when either is
+// non-empty; it is a no-op otherwise.
+func verifyChecksum(path, sha256Sum, md5Sum string) error {
+	if sha256Sum == "" && md5Sum == "" {
+		return nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if sha256Sum != "" {
+		h := sha256.New()
+		if _, err := io.Copy(h, f); err != nil {
+			return err
+		}
+		if got := hex.EncodeToString(h.Sum(nil)); !strings.EqualFold(got, sha256Sum) {
+			return fmt.Errorf("sha256 mismatch: got %s, want %s", got, sha256Sum)
+		}
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+	}
+
+	if md5Sum != "" {
+		h := md5.New()
+		if _, err := io.Copy(h, f); err != nil {
+			return err
+		}
+		if got := hex.EncodeToString(h.Sum(nil)); !strings.EqualFold(got, md5Sum) {
+			return fmt.Errorf("md5 mismatch: got %s, want %s", got, md5Sum)
+		}
+	}
+
+	return nil
+}
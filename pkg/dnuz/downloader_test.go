@@ -0,0 +1,157 @@
+// Copyright © 2018 Vyacheslav Dutka
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dnuz
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+// rangeServer serves content from a fixed byte slice, honouring Range
+// requests the way a standards-compliant static file server would.
+func rangeServer(t *testing.T, content []byte) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"test-etag"`)
+
+		rng := r.Header.Get("Range")
+		if rng == "" {
+			w.WriteHeader(http.StatusOK)
+			w.Write(content)
+			return
+		}
+
+		var offset int64
+		if _, err := fmt.Sscanf(rng, "bytes=%d-", &offset); err != nil || offset > int64(len(content)) {
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes */%d", len(content)))
+			w.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
+			return
+		}
+
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", offset, len(content)-1, len(content)))
+		w.WriteHeader(http.StatusPartialContent)
+		w.Write(content[offset:])
+	}))
+}
+
+func TestDownloadResumesFromPartialFile(t *testing.T) {
+	content := []byte(strings.Repeat("dnuz-test-content-", 100))
+	srv := rangeServer(t, content)
+	defer srv.Close()
+
+	dir := t.TempDir()
+	destPath := dir + "/out.bin"
+	partPath := destPath + ".part"
+	metaPath := destPath + ".meta"
+
+	// Simulate a previous run that got interrupted partway through.
+	split := len(content) / 2
+	if err := os.WriteFile(partPath, content[:split], 0644); err != nil {
+		t.Fatalf("seed part file: %v", err)
+	}
+	meta := downloadMeta{URL: srv.URL, ETag: `"test-etag"`, BytesReceived: int64(split)}
+	if err := saveMeta(metaPath, &meta); err != nil {
+		t.Fatalf("seed meta file: %v", err)
+	}
+
+	d := NewDownloader(WithProgress(false))
+	got, err := d.Download(srv.URL, destPath)
+	if err != nil {
+		t.Fatalf("Download: %v", err)
+	}
+	if got != destPath {
+		t.Fatalf("Download returned %q, want %q", got, destPath)
+	}
+
+	gotContent, err := os.ReadFile(destPath)
+	if err != nil {
+		t.Fatalf("reading downloaded file: %v", err)
+	}
+	if string(gotContent) != string(content) {
+		t.Fatalf("downloaded content has length %d, want %d", len(gotContent), len(content))
+	}
+
+	if _, err := os.Stat(partPath); !os.IsNotExist(err) {
+		t.Errorf(".part file still exists after a successful download: %v", err)
+	}
+	if _, err := os.Stat(metaPath); !os.IsNotExist(err) {
+		t.Errorf(".meta file still exists after a successful download: %v", err)
+	}
+}
+
+func TestDownloadChecksumFailureAllowsRedownload(t *testing.T) {
+	content := []byte("the real content")
+	srv := rangeServer(t, content)
+	defer srv.Close()
+
+	dir := t.TempDir()
+	destPath := dir + "/out.bin"
+	partPath := destPath + ".part"
+	metaPath := destPath + ".meta"
+
+	d := NewDownloader(WithProgress(false), WithChecksum(strings.Repeat("0", 64), ""))
+	if _, err := d.Download(srv.URL, destPath); err == nil {
+		t.Fatal("Download with a wrong checksum: want error, got nil")
+	}
+
+	if _, err := os.Stat(partPath); !os.IsNotExist(err) {
+		t.Fatalf(".part file should be removed after a checksum failure, stat err = %v", err)
+	}
+	if _, err := os.Stat(metaPath); !os.IsNotExist(err) {
+		t.Fatalf(".meta file should be removed after a checksum failure, stat err = %v", err)
+	}
+
+	// A later call (even with no checksum requirement at all) must be able
+	// to redownload from scratch instead of replaying a stale Range
+	// request against bytes that no longer match what's on disk.
+	d2 := NewDownloader(WithProgress(false))
+	got, err := d2.Download(srv.URL, destPath)
+	if err != nil {
+		t.Fatalf("Download after a prior checksum failure: %v", err)
+	}
+	gotContent, err := os.ReadFile(got)
+	if err != nil {
+		t.Fatalf("reading redownloaded file: %v", err)
+	}
+	if string(gotContent) != string(content) {
+		t.Fatalf("redownloaded content = %q, want %q", gotContent, content)
+	}
+}
+
+func TestVerifyChecksum(t *testing.T) {
+	dir := t.TempDir()
+	path := dir + "/f"
+	if err := os.WriteFile(path, []byte("hello"), 0644); err != nil {
+		t.Fatalf("write test file: %v", err)
+	}
+
+	wrongSHA256 := strings.Repeat("0", 64)
+	if err := verifyChecksum(path, wrongSHA256, ""); err == nil {
+		t.Fatal("verifyChecksum with a wrong digest: want error, got nil")
+	}
+
+	const helloSHA256 = "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"
+	if err := verifyChecksum(path, helloSHA256, ""); err != nil {
+		t.Fatalf("verifyChecksum with the correct digest: unexpected error: %v", err)
+	}
+
+	if err := verifyChecksum(path, "", ""); err != nil {
+		t.Fatalf("verifyChecksum with no digests requested: unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,169 @@
+// Copyright © 2018 Vyacheslav Dutka
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dnuz
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/ianaindex"
+	"golang.org/x/text/transform"
+
+	"github.com/vsdutka/dnuz/internal/archive"
+)
+
+// encodingAliases covers short-hand names dnuz accepted before this
+// lookup went through ianaindex.
+var encodingAliases = map[string]string{
+	"866":  "ibm866",
+	"1251": "windows-1251",
+}
+
+// getEncoding resolves encName against the IANA and MIME encoding indexes,
+// which between them cover every label x/text registers: charmap,
+// unicode, simplifiedchinese, traditionalchinese, japanese and korean.
+func getEncoding(encName string) (encoding.Encoding, error) {
+	if encName == "" {
+		return encoding.Nop, nil
+	}
+	name := strings.ToLower(encName)
+	if alias, ok := encodingAliases[name]; ok {
+		name = alias
+	}
+	if enc, err := ianaindex.IANA.Encoding(name); err == nil && enc != nil {
+		return enc, nil
+	}
+	if enc, err := ianaindex.MIME.Encoding(name); err == nil && enc != nil {
+		return enc, nil
+	}
+	return nil, fmt.Errorf("unsupported encoding name %q", encName)
+}
+
+func getDecoder(encName string) (transform.Transformer, error) {
+	e, err := getEncoding(encName)
+	if err != nil {
+		return nil, err
+	}
+	if e == nil {
+		return nil, nil
+	}
+	return e.NewDecoder(), nil
+}
+
+func getEncoder(encName string) (transform.Transformer, error) {
+	e, err := getEncoding(encName)
+	if err != nil {
+		return nil, err
+	}
+	if e == nil {
+		return nil, nil
+	}
+	return e.NewEncoder(), nil
+}
+
+// transformAll runs t over src to completion, growing its output buffer
+// whenever Transform reports ErrShortDst instead of giving up after a
+// single fixed-size attempt.
+func transformAll(t transform.Transformer, src []byte) (string, error) {
+	if t == nil {
+		return string(src), nil
+	}
+
+	buf := make([]byte, len(src)*2+16)
+	for {
+		n, _, err := t.Transform(buf, src, true)
+		if err == transform.ErrShortDst {
+			buf = make([]byte, len(buf)*2)
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		return string(buf[:n]), nil
+	}
+}
+
+// resolveDecoder turns nonUTF8Enc into a decoder: "" means entries are
+// already UTF-8, "auto" defers to detectEncoding over entries' raw
+// NonUTF8 names, anything else is looked up via getDecoder.
+func resolveDecoder(nonUTF8Enc string, entries []*archive.Entry) (transform.Transformer, error) {
+	if !strings.EqualFold(nonUTF8Enc, "auto") {
+		return getDecoder(nonUTF8Enc)
+	}
+
+	var raw []byte
+	for _, e := range entries {
+		if e.NonUTF8 {
+			raw = append(raw, []byte(e.Name)...)
+		}
+	}
+
+	name := detectEncoding(raw)
+	if name == "" {
+		return nil, nil
+	}
+	return getDecoder(name)
+}
+
+// detectEncoding guesses the single-byte/multi-byte Cyrillic or CJK
+// encoding family of raw, returning an encoding name accepted by
+// getEncoding, or "" if raw doesn't look like any of them (i.e. plain
+// ASCII/UTF-8).
+func detectEncoding(raw []byte) string {
+	var cp866, win1251, sjis, gbk int
+
+	for i := 0; i < len(raw); i++ {
+		b := raw[i]
+		if b < 0x80 {
+			continue
+		}
+
+		if i+1 < len(raw) {
+			b2 := raw[i+1]
+			if (b >= 0x81 && b <= 0x9f || b >= 0xe0 && b <= 0xfc) && b2 >= 0x40 && b2 <= 0xfc && b2 != 0x7f {
+				sjis++
+			}
+			if b >= 0x81 && b <= 0xfe && b2 >= 0x40 && b2 <= 0xfe && b2 != 0x7f {
+				gbk++
+			}
+		}
+
+		switch {
+		case b >= 0x80 && b <= 0x9f, b >= 0xb0 && b <= 0xdf, b >= 0xe0 && b <= 0xef:
+			cp866++
+		}
+		if b >= 0xc0 {
+			win1251++
+		}
+	}
+
+	best, bestScore := "", 0
+	for _, candidate := range []struct {
+		name  string
+		score int
+	}{
+		{"cp866", cp866},
+		{"windows-1251", win1251},
+		// two-byte matches are a stronger signal than single-byte ones
+		{"shift_jis", sjis * 2},
+		{"gbk", gbk * 2},
+	} {
+		if candidate.score > bestScore {
+			best, bestScore = candidate.name, candidate.score
+		}
+	}
+	return best
+}
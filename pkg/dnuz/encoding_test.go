@@ -0,0 +1,151 @@
+// Copyright © 2018 Vyacheslav Dutka
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dnuz
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/text/encoding/ianaindex"
+	"golang.org/x/text/transform"
+)
+
+func TestGetEncoding(t *testing.T) {
+	if _, err := getEncoding(""); err != nil {
+		t.Fatalf("getEncoding(\"\"): unexpected error: %v", err)
+	}
+
+	tests := []struct{ name, wantCanonical string }{
+		{"866", "ibm866"},
+		{"1251", "windows-1251"},
+		{"gbk", "gbk"},
+		{"SHIFT_JIS", "shift_jis"},
+	}
+	for _, tt := range tests {
+		enc, err := getEncoding(tt.name)
+		if err != nil {
+			t.Errorf("getEncoding(%q): unexpected error: %v", tt.name, err)
+			continue
+		}
+		if canonical, err := ianaindex.IANA.Name(enc); err != nil || !strings.EqualFold(canonical, tt.wantCanonical) {
+			t.Errorf("getEncoding(%q) resolved to %q (err %v), want %q", tt.name, canonical, err, tt.wantCanonical)
+		}
+	}
+
+	if _, err := getEncoding("not-a-real-encoding"); err == nil {
+		t.Fatal("getEncoding with an unknown name: want error, got nil")
+	}
+}
+
+// growTransformer always reports ErrShortDst until the caller offers a
+// buffer at least `needed` bytes long, exercising transformAll's
+// grow-and-retry loop without depending on a real encoding's output size.
+type growTransformer struct{ needed int }
+
+func (g *growTransformer) Transform(dst, src []byte, atEOF bool) (nDst, nSrc int, err error) {
+	if len(dst) < g.needed {
+		return 0, 0, transform.ErrShortDst
+	}
+	n := copy(dst, src)
+	return n, len(src), nil
+}
+
+func (g *growTransformer) Reset() {}
+
+func TestTransformAllGrowsBufferUntilItFits(t *testing.T) {
+	src := []byte("hi")
+	got, err := transformAll(&growTransformer{needed: 1000}, src)
+	if err != nil {
+		t.Fatalf("transformAll: unexpected error: %v", err)
+	}
+	if got != "hi" {
+		t.Fatalf("transformAll = %q, want %q", got, "hi")
+	}
+}
+
+func TestTransformAllNilTransformerIsIdentity(t *testing.T) {
+	got, err := transformAll(nil, []byte("unchanged"))
+	if err != nil {
+		t.Fatalf("transformAll(nil, ...): unexpected error: %v", err)
+	}
+	if got != "unchanged" {
+		t.Fatalf("transformAll(nil, ...) = %q, want %q", got, "unchanged")
+	}
+}
+
+func TestDetectEncoding(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  []byte
+		want string
+	}{
+		{"plain ascii", []byte("readme.txt"), ""},
+		// 0x85 alone (cp866's uppercase-Cyrillic range, below win1251's
+		// 0xc0 floor, not part of any sjis/gbk lead byte at end of input).
+		{"cp866 single byte", append([]byte("file_"), 0x85), "cp866"},
+		// 0xf5 alone is >=0xc0 (win1251) but outside every cp866 range.
+		{"windows-1251 single byte", append([]byte("file_"), 0xf5), "windows-1251"},
+		// 0x82 0xa0 is a valid double-byte lead/trail pair for both sjis
+		// and gbk; shift_jis sorts first among equal-scoring candidates.
+		{"shift_jis double byte", []byte{0x82, 0xa0}, "shift_jis"},
+		// 0xb0 0x41: a lead/trail pair gbk accepts but sjis's narrower
+		// lead-byte range (0x81-9f, 0xe0-fc) does not.
+		{"gbk double byte", []byte{0xb0, 0x41}, "gbk"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := detectEncoding(tt.raw); got != tt.want {
+				t.Errorf("detectEncoding(% x) = %q, want %q", tt.raw, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEncodeDecodeRoundTrip(t *testing.T) {
+	tests := []struct{ encName, want string }{
+		{"cp866", "привет_мир.txt"},
+		{"windows-1251", "привет_мир.txt"},
+		{"gbk", "你好世界.txt"},
+		{"shift_jis", "こんにちは.txt"},
+	}
+
+	for _, tt := range tests {
+		encName, want := tt.encName, tt.want
+		t.Run(encName, func(t *testing.T) {
+			enc, err := getEncoder(encName)
+			if err != nil {
+				t.Fatalf("getEncoder(%q): %v", encName, err)
+			}
+			raw, err := transformAll(enc, []byte(want))
+			if err != nil {
+				t.Fatalf("encoding via %q: %v", encName, err)
+			}
+
+			dec, err := getDecoder(encName)
+			if err != nil {
+				t.Fatalf("getDecoder(%q): %v", encName, err)
+			}
+			got, err := transformAll(dec, []byte(raw))
+			if err != nil {
+				t.Fatalf("decoding via %q: %v", encName, err)
+			}
+
+			if got != want {
+				t.Errorf("round trip through %q = %q, want %q", encName, got, want)
+			}
+		})
+	}
+}
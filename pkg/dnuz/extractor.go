@@ -0,0 +1,311 @@
+// Copyright © 2018 Vyacheslav Dutka
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dnuz
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/spf13/afero"
+	"golang.org/x/text/transform"
+
+	"github.com/vsdutka/dnuz/internal/archive"
+)
+
+// Extractor unpacks an archive on disk, fixing up non-UTF8 filenames and
+// writing the result through its configured filesystem.
+type Extractor struct {
+	opts *options
+}
+
+// NewExtractor builds an Extractor. See WithFormat, WithEncoding,
+// WithOutEncoding, WithFS and WithLimits for the options it understands.
+func NewExtractor(opts ...Option) *Extractor {
+	o := defaultOptions()
+	for _, opt := range opts {
+		opt(o)
+	}
+	return &Extractor{opts: o}
+}
+
+// symlinkFS is implemented by filesystems (afero's OsFs among them) that
+// can create symlinks; afero.Fs itself has no such method, since
+// in-memory filesystems generally can't represent one.
+type symlinkFS interface {
+	SymlinkIfPossible(oldname, newname string) error
+}
+
+// Extract opens archivePath and writes its entries under outPath,
+// returning metadata for everything it wrote.
+func (x *Extractor) Extract(archivePath, outPath string) ([]ExtractedFile, error) {
+	format := x.opts.format
+	if format == "" {
+		f, err := sniffFormat(archivePath)
+		if err != nil {
+			return nil, err
+		}
+		format = f
+	}
+
+	ex, err := archive.New(format, archivePath)
+	if err != nil {
+		return nil, err
+	}
+	defer ex.Close()
+
+	entries, err := ex.Entries()
+	if err != nil {
+		return nil, err
+	}
+
+	decoder, err := resolveDecoder(x.opts.nonUTF8Enc, entries)
+	if err != nil {
+		return nil, err
+	}
+	encoder, err := getEncoder(x.opts.outEnc)
+	if err != nil {
+		return nil, err
+	}
+
+	limits := &extractionLimits{
+		maxFiles:     x.opts.maxFiles,
+		maxTotalSize: x.opts.maxTotalSize,
+		maxFileSize:  x.opts.maxFileSize,
+	}
+
+	// Path resolution touches shared decoder/limits state and has to run
+	// in entry order, so it stays sequential; only the actual opening,
+	// copying and hashing of each entry is fanned out below.
+	fpaths := make([]string, len(entries))
+	decodedNames := make([]string, len(entries))
+	for i, e := range entries {
+		fpath, decodedName, err := x.resolvePath(e, decoder, encoder, outPath, limits)
+		if err != nil {
+			return nil, err
+		}
+		fpaths[i] = fpath
+		decodedNames[i] = decodedName
+	}
+
+	files, err := x.extractAll(entries, fpaths, decodedNames, outPath)
+	if err != nil {
+		return files, err
+	}
+
+	if x.opts.manifestPath != "" {
+		if err := writeManifest(x.opts.fs, x.opts.manifestPath, files); err != nil {
+			return files, err
+		}
+	}
+	return files, nil
+}
+
+// extractAll dispatches entries to a bounded worker pool, each worker
+// opening and closing its own file handle per entry rather than holding
+// every one open until the whole archive finishes.
+func (x *Extractor) extractAll(entries []*archive.Entry, fpaths, decodedNames []string, outPath string) ([]ExtractedFile, error) {
+	numWorkers := x.opts.jobs
+	if numWorkers < 1 {
+		numWorkers = 1
+	}
+	if numWorkers > len(entries) {
+		numWorkers = len(entries)
+	}
+
+	results := make([]ExtractedFile, len(entries))
+	errs := make([]error, len(entries))
+	dirs := &dirCache{fs: x.opts.fs}
+
+	jobCh := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < numWorkers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobCh {
+				results[i], errs[i] = x.extractOne(entries[i], fpaths[i], decodedNames[i], outPath, dirs)
+			}
+		}()
+	}
+	for i := range entries {
+		jobCh <- i
+	}
+	close(jobCh)
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return results, err
+		}
+	}
+	return results, nil
+}
+
+// extractOne writes a single entry: MkdirAll for a directory entry,
+// os.Symlink for a symlink entry, or a hashed copy for everything else.
+func (x *Extractor) extractOne(e *archive.Entry, fpath, decodedName, outPath string, dirs *dirCache) (ExtractedFile, error) {
+	if e.IsDir {
+		if err := dirs.ensure(fpath); err != nil {
+			return ExtractedFile{}, err
+		}
+		return ExtractedFile{Name: e.Name, DecodedName: decodedName, Path: fpath, Mode: e.Mode, IsDir: true, NonUTF8: e.NonUTF8}, nil
+	}
+
+	if err := dirs.ensure(filepath.Dir(fpath)); err != nil {
+		return ExtractedFile{}, err
+	}
+
+	rc, err := e.Open()
+	if err != nil {
+		return ExtractedFile{}, err
+	}
+
+	if e.IsSymlink {
+		n, err := x.writeSymlink(e, rc, outPath, fpath)
+		if err != nil {
+			return ExtractedFile{}, err
+		}
+		return ExtractedFile{Name: e.Name, DecodedName: decodedName, Path: fpath, Size: n, NonUTF8: e.NonUTF8}, nil
+	}
+
+	mode := e.Mode
+	if mode == 0 {
+		mode = os.ModePerm
+	}
+	n, digest, err := x.writeFile(rc, fpath, mode)
+	if err != nil {
+		return ExtractedFile{}, err
+	}
+	return ExtractedFile{Name: e.Name, DecodedName: decodedName, Path: fpath, Size: n, Mode: mode, NonUTF8: e.NonUTF8, SHA256: digest}, nil
+}
+
+// dirCache serializes directory creation across workers via a sync.Map of
+// already-created paths, so concurrent entries under the same directory
+// don't all call MkdirAll.
+type dirCache struct {
+	fs      afero.Fs
+	created sync.Map
+}
+
+func (d *dirCache) ensure(dir string) error {
+	mkdir, _ := d.created.LoadOrStore(dir, sync.OnceValue(func() error {
+		return d.fs.MkdirAll(dir, os.ModePerm)
+	}))
+	return mkdir.(func() error)()
+}
+
+// resolvePath decodes e's name, sanitizes it against outPath and checks
+// it against the extraction limits. It returns the final path to write to
+// and the decoded name (e's name after non-UTF8 decoding but before
+// sanitizing or re-encoding), for the caller to record in a manifest.
+func (x *Extractor) resolvePath(e *archive.Entry, decoder, encoder transform.Transformer, outPath string, limits *extractionLimits) (string, string, error) {
+	if !e.IsDir {
+		if err := limits.check(e.Name, e.Size); err != nil {
+			return "", "", err
+		}
+	}
+
+	fname := e.Name
+	if e.NonUTF8 && decoder != nil {
+		// Разные кодировки = разные длины символов.
+		decoded, err := transformAll(decoder, []byte(fname))
+		if err != nil {
+			return "", "", &extractionError{e.Name, "decode: " + err.Error()}
+		}
+		fname = decoded
+	}
+	decodedName := fname
+
+	fpath, err := sanitizePath(outPath, fname)
+	if err != nil {
+		return "", "", err
+	}
+	fpath = strings.ToLower(fpath)
+
+	if encoder != nil {
+		encoded, err := transformAll(encoder, []byte(fpath))
+		if err != nil {
+			return "", "", &extractionError{e.Name, "encode: " + err.Error()}
+		}
+		fpath = encoded
+	}
+	return fpath, decodedName, nil
+}
+
+// writeFile copies rc to fpath, tee-hashing the content as it goes so the
+// caller gets a SHA-256 digest without a second pass over the file.
+func (x *Extractor) writeFile(rc io.ReadCloser, fpath string, mode os.FileMode) (int64, string, error) {
+	defer rc.Close()
+
+	out, err := x.opts.fs.OpenFile(fpath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+	if err != nil {
+		return 0, "", err
+	}
+	defer out.Close()
+
+	h := sha256.New()
+	n, err := io.Copy(io.MultiWriter(out, h), rc)
+	if err != nil {
+		return 0, "", err
+	}
+	return n, hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func (x *Extractor) writeSymlink(e *archive.Entry, rc io.ReadCloser, outPath, fpath string) (int64, error) {
+	target, err := ioutil.ReadAll(rc)
+	rc.Close()
+	if err != nil {
+		return 0, err
+	}
+
+	if err := sanitizeLinkTarget(outPath, fpath, string(target)); err != nil {
+		return 0, &extractionError{e.Name, err.Error()}
+	}
+
+	linker, ok := x.opts.fs.(symlinkFS)
+	if !ok {
+		x.opts.logger.Warn("skipping symlink entry: filesystem can't create symlinks", "entry", e.Name)
+		return 0, nil
+	}
+
+	x.opts.fs.Remove(fpath)
+	if err := linker.SymlinkIfPossible(string(target), fpath); err != nil {
+		return 0, err
+	}
+	return int64(len(target)), nil
+}
+
+// sniffFormat resolves path's archive format from its leading magic
+// bytes.
+func sniffFormat(path string) (archive.Format, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	head := make([]byte, 512)
+	n, err := f.Read(head)
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+	return archive.Sniff(head[:n]), nil
+}
@@ -0,0 +1,131 @@
+// Copyright © 2018 Vyacheslav Dutka
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dnuz
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/afero"
+)
+
+// writeTestZip builds a zip archive at dir/name.zip containing entries and
+// returns its path. A real file is used because the archive package opens
+// archives by path; only extraction output goes through afero.
+func writeTestZip(t *testing.T, dir, name string, entries map[string][]byte, symlinks map[string]string) string {
+	t.Helper()
+
+	path := filepath.Join(dir, name)
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("create zip: %v", err)
+	}
+	defer f.Close()
+
+	zw := zip.NewWriter(f)
+	for entryName, content := range entries {
+		w, err := zw.Create(entryName)
+		if err != nil {
+			t.Fatalf("create entry %q: %v", entryName, err)
+		}
+		if _, err := w.Write(content); err != nil {
+			t.Fatalf("write entry %q: %v", entryName, err)
+		}
+	}
+	for entryName, target := range symlinks {
+		hdr := &zip.FileHeader{Name: entryName}
+		hdr.SetMode(os.ModeSymlink | 0777)
+		w, err := zw.CreateHeader(hdr)
+		if err != nil {
+			t.Fatalf("create symlink entry %q: %v", entryName, err)
+		}
+		if _, err := w.Write([]byte(target)); err != nil {
+			t.Fatalf("write symlink entry %q: %v", entryName, err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("close zip: %v", err)
+	}
+	return path
+}
+
+func TestExtractWritesFilesUnderOutPath(t *testing.T) {
+	dir := t.TempDir()
+	archivePath := writeTestZip(t, dir, "plain.zip", map[string][]byte{
+		"a/b/file.txt": []byte("hello"),
+	}, nil)
+
+	fs := afero.NewMemMapFs()
+	x := NewExtractor(WithFS(fs), WithProgress(false))
+
+	files, err := x.Extract(archivePath, "/out")
+	if err != nil {
+		t.Fatalf("Extract: %v", err)
+	}
+	if len(files) != 1 {
+		t.Fatalf("got %d files, want 1", len(files))
+	}
+	if want := filepath.FromSlash("/out/a/b/file.txt"); files[0].Path != want {
+		t.Fatalf("extracted path = %q, want %q", files[0].Path, want)
+	}
+
+	got, err := afero.ReadFile(fs, files[0].Path)
+	if err != nil {
+		t.Fatalf("reading extracted file: %v", err)
+	}
+	if string(got) != "hello" {
+		t.Fatalf("extracted content = %q, want %q", got, "hello")
+	}
+	if files[0].SHA256 == "" {
+		t.Fatal("extracted file has no SHA256 digest")
+	}
+}
+
+func TestExtractRejectsPathTraversal(t *testing.T) {
+	dir := t.TempDir()
+	archivePath := writeTestZip(t, dir, "slip.zip", map[string][]byte{
+		"../../etc/passwd": []byte("pwned"),
+	}, nil)
+
+	x := NewExtractor(WithFS(afero.NewMemMapFs()), WithProgress(false))
+
+	_, err := x.Extract(archivePath, "/out")
+	if err == nil {
+		t.Fatal("Extract with a zip-slip entry: want error, got nil")
+	}
+	if !strings.Contains(err.Error(), "escapes output directory") {
+		t.Fatalf("Extract error = %q, want it to mention escaping the output directory", err)
+	}
+}
+
+func TestExtractRejectsEscapingSymlink(t *testing.T) {
+	dir := t.TempDir()
+	archivePath := writeTestZip(t, dir, "symlink.zip", nil, map[string]string{
+		"link": "../../etc/passwd",
+	})
+
+	x := NewExtractor(WithFS(afero.NewMemMapFs()), WithProgress(false))
+
+	_, err := x.Extract(archivePath, "/out")
+	if err == nil {
+		t.Fatal("Extract with an escaping symlink target: want error, got nil")
+	}
+	if !strings.Contains(err.Error(), "escapes output directory") {
+		t.Fatalf("Extract error = %q, want it to mention escaping the output directory", err)
+	}
+}
@@ -0,0 +1,95 @@
+// Copyright © 2018 Vyacheslav Dutka
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dnuz
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/afero"
+)
+
+// manifestEntry is one record in the JSON manifest format.
+type manifestEntry struct {
+	Name        string `json:"name"`
+	DecodedName string `json:"decoded_name,omitempty"`
+	Path        string `json:"path"`
+	Size        int64  `json:"size"`
+	Mode        string `json:"mode"`
+	SHA256      string `json:"sha256,omitempty"`
+}
+
+// writeManifest records files (directories and symlinks excluded, since
+// neither has a meaningful digest) to path, through fs.
+func writeManifest(fs afero.Fs, path string, files []ExtractedFile) error {
+	if strings.HasSuffix(strings.ToLower(path), ".json") {
+		return writeJSONManifest(fs, path, files)
+	}
+	return writeSHA256SumManifest(fs, path, files)
+}
+
+func writeJSONManifest(fs afero.Fs, path string, files []ExtractedFile) error {
+	entries := make([]manifestEntry, 0, len(files))
+	for _, f := range files {
+		if f.IsDir || f.SHA256 == "" {
+			continue
+		}
+		entry := manifestEntry{
+			Name:   f.Name,
+			Path:   f.Path,
+			Size:   f.Size,
+			Mode:   f.Mode.String(),
+			SHA256: f.SHA256,
+		}
+		if f.DecodedName != f.Name {
+			entry.DecodedName = f.DecodedName
+		}
+		entries = append(entries, entry)
+	}
+
+	b, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	out, err := fs.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	_, err = out.Write(b)
+	return err
+}
+
+func writeSHA256SumManifest(fs afero.Fs, path string, files []ExtractedFile) error {
+	out, err := fs.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	for _, f := range files {
+		if f.IsDir || f.SHA256 == "" {
+			continue
+		}
+		if _, err := fmt.Fprintf(out, "%s  %s\n", f.SHA256, f.Path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
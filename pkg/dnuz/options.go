@@ -0,0 +1,152 @@
+// Copyright © 2018 Vyacheslav Dutka
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dnuz
+
+import (
+	"net/http"
+	"runtime"
+	"time"
+
+	"github.com/spf13/afero"
+
+	"github.com/vsdutka/dnuz/internal/archive"
+)
+
+// Option configures a Downloader or Extractor. Unrecognised options for a
+// given type are simply ignored (e.g. WithFS has no effect on a
+// Downloader, which always streams to a real file).
+type Option func(*options)
+
+type options struct {
+	logger Logger
+
+	// Downloader
+	client    *http.Client
+	retries   int
+	retryWait time.Duration
+	progress  bool
+	sha256    string
+	md5       string
+
+	// Extractor
+	fs           afero.Fs
+	format       archive.Format
+	nonUTF8Enc   string
+	outEnc       string
+	maxFiles     int
+	maxTotalSize int64
+	maxFileSize  int64
+	jobs         int
+	manifestPath string
+}
+
+func defaultOptions() *options {
+	return &options{
+		logger:    defaultLogger(),
+		client:    http.DefaultClient,
+		retries:   3,
+		retryWait: 2 * time.Second,
+		progress:  true,
+		fs:        afero.NewOsFs(),
+		jobs:      runtime.NumCPU(),
+	}
+}
+
+// WithLogger sets the Logger used for progress and diagnostic messages.
+func WithLogger(l Logger) Option {
+	return func(o *options) { o.logger = l }
+}
+
+// WithHTTPClient sets the HTTP client a Downloader uses, e.g. to configure
+// timeouts or a custom transport.
+func WithHTTPClient(c *http.Client) Option {
+	return func(o *options) { o.client = c }
+}
+
+// WithRetries sets how many times a Downloader retries a failed download
+// and how long it waits between attempts.
+func WithRetries(retries int, wait time.Duration) Option {
+	return func(o *options) { o.retries, o.retryWait = retries, wait }
+}
+
+// WithProgress enables or disables the Downloader's progress bar.
+func WithProgress(enabled bool) Option {
+	return func(o *options) { o.progress = enabled }
+}
+
+// WithChecksum makes Download fail if the downloaded file doesn't match
+// the given sha256 and/or md5 hex digest. Pass "" for a digest you don't
+// want checked.
+func WithChecksum(sha256Sum, md5Sum string) Option {
+	return func(o *options) { o.sha256, o.md5 = sha256Sum, md5Sum }
+}
+
+// WithFS makes an Extractor write through fs instead of the OS
+// filesystem, e.g. afero.NewMemMapFs() for in-memory tests.
+func WithFS(fs afero.Fs) Option {
+	return func(o *options) { o.fs = fs }
+}
+
+// WithFormat overrides an Extractor's archive format instead of sniffing
+// it from the archive's magic bytes.
+func WithFormat(format archive.Format) Option {
+	return func(o *options) { o.format = format }
+}
+
+// WithFormatName is WithFormat for callers (such as a --format flag) that
+// only have the format as a string.
+func WithFormatName(name string) Option {
+	return WithFormat(archive.Format(name))
+}
+
+// WithEncoding sets the encoding non-UTF8 entry names are decoded from,
+// e.g. "cp866", "gbk", or "auto" to detect it from the archive's raw
+// names.
+func WithEncoding(name string) Option {
+	return func(o *options) { o.nonUTF8Enc = name }
+}
+
+// WithOutEncoding sets the encoding extracted paths are re-encoded to.
+func WithOutEncoding(name string) Option {
+	return func(o *options) { o.outEnc = name }
+}
+
+// WithLimits bounds an extraction as a zip-bomb guard: at most maxFiles
+// non-directory entries, maxTotalSize total uncompressed bytes, and
+// maxFileSize bytes for any one file. Directory entries count towards
+// none of the limits. Zero means unlimited.
+func WithLimits(maxFiles int, maxTotalSize, maxFileSize int64) Option {
+	return func(o *options) {
+		o.maxFiles, o.maxTotalSize, o.maxFileSize = maxFiles, maxTotalSize, maxFileSize
+	}
+}
+
+// WithJobs sets how many entries an Extractor writes out concurrently.
+// n <= 0 means runtime.NumCPU(), which is also the default.
+func WithJobs(n int) Option {
+	if n <= 0 {
+		n = runtime.NumCPU()
+	}
+	return func(o *options) { o.jobs = n }
+}
+
+// WithManifest makes Extract record a manifest of the regular files it
+// wrote to path; directories and symlinks are left out. A ".json" path
+// gets a JSON array recording each file's original name, decoded name
+// (when it differs), final path, size, mode and SHA-256 digest; anything
+// else gets "sha256sum"-style lines ("<digest>  <path>").
+func WithManifest(path string) Option {
+	return func(o *options) { o.manifestPath = path }
+}
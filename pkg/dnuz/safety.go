@@ -0,0 +1,93 @@
+// Copyright © 2018 Vyacheslav Dutka
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dnuz
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// extractionError reports which archive entry extraction failed on and
+// why, so callers see a structured reason instead of a panic.
+type extractionError struct {
+	Entry  string
+	Reason string
+}
+
+func (e *extractionError) Error() string {
+	return fmt.Sprintf("entry %q: %s", e.Entry, e.Reason)
+}
+
+// sanitizePath joins name onto outPath and rejects the result if name is
+// an absolute path or its cleaned path escapes outPath (zip-slip).
+func sanitizePath(outPath, name string) (string, error) {
+	if filepath.IsAbs(name) {
+		return "", &extractionError{name, "absolute paths are not allowed"}
+	}
+
+	cleanOutPath := filepath.Clean(outPath)
+	fpath := filepath.Clean(filepath.Join(cleanOutPath, name))
+	if rel, err := filepath.Rel(cleanOutPath, fpath); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(os.PathSeparator)) {
+		return "", &extractionError{name, "path escapes output directory"}
+	}
+	return fpath, nil
+}
+
+// sanitizeLinkTarget rejects a symlink entry whose target, resolved
+// relative to fpath's directory, would point outside outPath.
+func sanitizeLinkTarget(outPath, fpath, target string) error {
+	cleanOutPath := filepath.Clean(outPath)
+
+	resolved := target
+	if !filepath.IsAbs(target) {
+		resolved = filepath.Join(filepath.Dir(fpath), target)
+	}
+	resolved = filepath.Clean(resolved)
+
+	if rel, err := filepath.Rel(cleanOutPath, resolved); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(os.PathSeparator)) {
+		return fmt.Errorf("symlink target %q escapes output directory", target)
+	}
+	return nil
+}
+
+// extractionLimits tracks the zip-bomb guards (WithLimits) across an
+// extraction run.
+type extractionLimits struct {
+	maxFiles     int
+	maxTotalSize int64
+	maxFileSize  int64
+
+	files     int
+	totalSize int64
+}
+
+func (l *extractionLimits) check(name string, size int64) error {
+	if l.maxFileSize > 0 && size > l.maxFileSize {
+		return &extractionError{name, fmt.Sprintf("file size %d exceeds max file size %d", size, l.maxFileSize)}
+	}
+
+	l.files++
+	if l.maxFiles > 0 && l.files > l.maxFiles {
+		return &extractionError{name, fmt.Sprintf("extraction exceeds max files %d", l.maxFiles)}
+	}
+
+	l.totalSize += size
+	if l.maxTotalSize > 0 && l.totalSize > l.maxTotalSize {
+		return &extractionError{name, fmt.Sprintf("extraction exceeds max total size %d", l.maxTotalSize)}
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+// Copyright © 2018 Vyacheslav Dutka
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dnuz
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSanitizePath(t *testing.T) {
+	outPath := filepath.FromSlash("/out")
+
+	tests := []struct {
+		name    string
+		entry   string
+		wantErr bool
+	}{
+		{"plain file", "foo.txt", false},
+		{"nested file", "a/b/foo.txt", false},
+		{"absolute path", "/etc/passwd", true},
+		{"simple traversal", "../../etc/passwd", true},
+		{"traversal inside a nested path", "a/../../etc/passwd", true},
+		{"traversal that stays inside outPath", "a/../b.txt", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fpath, err := sanitizePath(outPath, tt.entry)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("sanitizePath(%q) = %q, want error", tt.entry, fpath)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("sanitizePath(%q) unexpected error: %v", tt.entry, err)
+			}
+			if !strings.HasPrefix(fpath, outPath+string(filepath.Separator)) {
+				t.Fatalf("sanitizePath(%q) = %q, want it under %q", tt.entry, fpath, outPath)
+			}
+		})
+	}
+}
+
+func TestSanitizeLinkTarget(t *testing.T) {
+	outPath := filepath.FromSlash("/out")
+	fpath := filepath.FromSlash("/out/link")
+
+	tests := []struct {
+		name    string
+		target  string
+		wantErr bool
+	}{
+		{"relative target inside outPath", "foo.txt", false},
+		{"relative target escaping outPath", "../outside.txt", true},
+		{"absolute target outside outPath", "/etc/passwd", true},
+		{"absolute target inside outPath", filepath.FromSlash("/out/foo.txt"), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := sanitizeLinkTarget(outPath, fpath, tt.target)
+			if tt.wantErr && err == nil {
+				t.Fatalf("sanitizeLinkTarget(%q) = nil, want error", tt.target)
+			}
+			if !tt.wantErr && err != nil {
+				t.Fatalf("sanitizeLinkTarget(%q) unexpected error: %v", tt.target, err)
+			}
+		})
+	}
+}
+
+func TestExtractionLimits(t *testing.T) {
+	l := &extractionLimits{maxFiles: 2, maxTotalSize: 10, maxFileSize: 6}
+
+	if err := l.check("a", 5); err != nil {
+		t.Fatalf("first file within limits: unexpected error: %v", err)
+	}
+	if err := l.check("b", 7); err == nil {
+		t.Fatal("file exceeding maxFileSize: want error, got nil")
+	}
+
+	l = &extractionLimits{maxFiles: 1}
+	if err := l.check("a", 1); err != nil {
+		t.Fatalf("first file within maxFiles: unexpected error: %v", err)
+	}
+	if err := l.check("b", 1); err == nil {
+		t.Fatal("file exceeding maxFiles: want error, got nil")
+	}
+
+	l = &extractionLimits{maxTotalSize: 10}
+	if err := l.check("a", 6); err != nil {
+		t.Fatalf("first file within maxTotalSize: unexpected error: %v", err)
+	}
+	if err := l.check("b", 6); err == nil {
+		t.Fatal("total size exceeding maxTotalSize: want error, got nil")
+	}
+}